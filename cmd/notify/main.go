@@ -10,7 +10,8 @@
 // The interval has a default value 5 seconds, but can be specified in a flag
 //
 // The utility listens for SIGINT, SIGTERM
-// On signal, Sender stops sending new messages, but will wait for workers to complete
+// On signal, Sender stops sending new messages, then Notifier.Shutdown is
+// given up to --shutdown-timeout seconds to deliver whatever is in flight
 //
 // HandleErrors receives failed or cancelled messages from Notifier package to print basic counter
 package main
@@ -34,20 +35,24 @@ const parserqSize = 400
 const senderqSize = 200
 
 var (
-	url      string
-	interval int // Two flags sharing the variable, so we can have a shorthand.
+	url             string
+	interval        int // Two flags sharing the variable, so we can have a shorthand.
+	shutdownTimeout int
 )
 
 func init() {
 	const (
-		intervalDefault = 5 // Default sending interval
-		intervalUsage   = "Notification interval, sec"
-		urlUsage        = "Target server url for sending notifications"
+		intervalDefault        = 5  // Default sending interval
+		intervalUsage          = "Notification interval, sec"
+		urlUsage               = "Target server url for sending notifications"
+		shutdownTimeoutDefault = 10 // Default grace period for in-flight messages on shutdown
+		shutdownTimeoutUsage   = "Grace period for in-flight messages to be delivered on shutdown, sec"
 	)
 
 	flag.IntVar(&interval, "interval", intervalDefault, intervalUsage)
 	flag.IntVar(&interval, "i", intervalDefault, intervalUsage+" (shorthand)") // short interval flag
 	flag.StringVar(&url, "url", "", urlUsage)
+	flag.IntVar(&shutdownTimeout, "shutdown-timeout", shutdownTimeoutDefault, shutdownTimeoutUsage)
 
 	// Take log level from env variable or default
 	s, _ := os.LookupEnv("LOG_LEVEL")
@@ -109,6 +114,13 @@ func main() {
 	// Start Sender
 	Sender(ctx, n, parserq, interval)
 
+	// Allow in-flight and queued messages up to shutdownTimeout to be delivered
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Duration(shutdownTimeout)*time.Second)
+	defer shutdownCancel()
+	if err := n.Shutdown(shutdownCtx); err != nil {
+		log.Warn(err)
+	}
+
 	log.Info("process complete")
 }
 
@@ -142,12 +154,12 @@ func Parser(ctx context.Context, in *bufio.Scanner, out chan<- notifier.Message)
 
 // Sender reads from in channel, collects messages into a local buffered channel
 // Every <interval> * seconds flushes collected messages to Notifier
+// Messages keep whatever Topic they arrived with, so Notifier.Send can
+// batch and route them per topic
 func Sender(ctx context.Context, n *notifier.Notifier, in <-chan notifier.Message, interval int) {
 	// Setup timer for intervals
 	ticker := time.NewTicker(time.Duration(interval) * time.Second)
 	defer ticker.Stop()
-	// Allow Notifier to complete
-	defer n.Stop()
 
 	// Init sender queue to avoid sending all at once (buffered)
 	senderq := make(chan notifier.Message, senderqSize)
@@ -161,7 +173,7 @@ func Sender(ctx context.Context, n *notifier.Notifier, in <-chan notifier.Messag
 			messages := queueToSlice(senderq)
 			messages = append(messages, m) // append missing message on this iteration
 			// Send collected messages
-			n.Send(messages)
+			n.Send(ctx, messages)
 		case senderq <- m:
 			// the senderq is full, waiting for timer to proceed
 		case <-ctx.Done():
@@ -172,10 +184,14 @@ func Sender(ctx context.Context, n *notifier.Notifier, in <-chan notifier.Messag
 		}
 	} // for range in
 
-	// Check the queue and send the rest
+	// Check the queue and send the rest. Use a fresh context rather than
+	// ctx: ctx may already be canceled here (a SIGINT or SIGTERM also stops
+	// Parser, closing in), and Send's enqueue races Send's own ctx.Done
+	// against the channel send, which would 50/50 drop this final batch
+	// instead of handing it to Notifier.Shutdown's deadline-bound drain.
 	<-ticker.C
 	messages := queueToSlice(senderq)
-	n.Send(messages)
+	n.Send(context.Background(), messages)
 }
 
 // queueToSlice reads from a buffered channel all items into a slice, then returns the slice
@@ -192,8 +208,8 @@ func queueToSlice(q <-chan notifier.Message) []notifier.Message {
 	}
 }
 
-// Handles failed messages
-// TODO: retry logic or printing into a separate error log
+// Handles messages which failed permanently (retries are handled inside Notifier)
+// TODO: printing into a separate error log
 func HandleErrors(in <-chan notifier.Message) {
 	i := 0
 	for m := range in {
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// How many pending SSE frames we buffer for a single slow subscriber
+// before dropping new ones, rather than blocking the POST handler
+const clientBufSize = 16
+
+// client is one connected SSE subscriber
+type client struct {
+	ch   chan []byte
+	done chan struct{} // closing it makes subscribe return and disconnect this client; nothing in this server closes it
+}
+
+// hub fans incoming /notify payloads out to every connected /subscribe client
+type hub struct {
+	mu      sync.Mutex
+	clients map[*client]struct{}
+}
+
+func newHub() *hub {
+	return &hub{clients: make(map[*client]struct{})}
+}
+
+// broadcast fans body out to every connected client. A client whose buffer
+// is full gets the event dropped instead of blocking the caller.
+func (h *hub) broadcast(body []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		select {
+		case c.ch <- body:
+		default:
+			log.Println("subscriber buffer full, dropping event")
+		}
+	}
+}
+
+// subscribe upgrades the request into a Server-Sent Events stream and
+// writes every broadcast body as a "data:" frame until the client
+// disconnects. Each client has its own done channel and buffered ch, so a
+// slow or gone subscriber can never block broadcast or another subscriber.
+func (h *hub) subscribe(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering in front of us
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	c := &client{
+		ch:   make(chan []byte, clientBufSize),
+		done: make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, c)
+		h.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case body := <-c.ch:
+			// A batched flush joins same-topic messages with "\n", so the
+			// payload can span multiple lines; SSE requires "data: " to be
+			// repeated on every line or a compliant EventSource drops all
+			// but the first
+			for _, line := range bytes.Split(body, []byte("\n")) {
+				if _, err := fmt.Fprintf(w, "data: %s\n", line); err != nil {
+					return
+				}
+			}
+			if _, err := fmt.Fprint(w, "\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-c.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -13,7 +14,14 @@ func inc() {
 	atomic.AddInt32(&totalRequests, 1)
 }
 
+// notifyMessage mirrors the fields of notifier.Message relevant to the receiver
+type notifyMessage struct {
+	Body  string `json:"Body"`
+	Topic string `json:"Topic"`
+}
+
 func main() {
+	h := newHub()
 
 	http.HandleFunc("/notify", func(w http.ResponseWriter, r *http.Request) {
 		inc()
@@ -24,7 +32,21 @@ func main() {
 			return
 		}
 
-		log.Println(totalRequests, string(body))
+		// A batched payload arrives as a JSON array, one object per message
+		if r.Header.Get("Content-Type") == "application/json" {
+			var messages []notifyMessage
+			if err := json.Unmarshal(body, &messages); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for _, m := range messages {
+				log.Println(totalRequests, m.Topic, m.Body)
+			}
+		} else {
+			log.Println(totalRequests, string(body))
+		}
+
+		h.broadcast(body)
 
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusOK)
@@ -35,6 +57,9 @@ func main() {
 		}
 	})
 
+	// GET /subscribe streams every /notify payload to the caller as Server-Sent Events
+	http.HandleFunc("/subscribe", h.subscribe)
+
 	err := http.ListenAndServe(":8080", nil)
 	if err != nil {
 		log.Fatal("can not start server")
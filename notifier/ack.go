@@ -0,0 +1,36 @@
+package notifier
+
+import "sync"
+
+// ackTracker tracks which store sequence numbers have been handled
+// (delivered, or given up on) so the WAL's front can be advanced past
+// the longest contiguous handled prefix, even when workers finish
+// out of order.
+type ackTracker struct {
+	mu      sync.Mutex
+	next    uint64          // oldest seq still outstanding
+	pending map[uint64]bool // seqs > next that were handled out of order
+}
+
+func newAckTracker(next uint64) *ackTracker {
+	return &ackTracker{next: next, pending: make(map[uint64]bool)}
+}
+
+// ack marks seq as handled and returns the new contiguous-prefix boundary
+// (the oldest seq still outstanding) if it advanced, or 0 if it did not
+func (a *ackTracker) ack(seq uint64) uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if seq != a.next {
+		a.pending[seq] = true
+		return 0
+	}
+
+	a.next++
+	for a.pending[a.next] {
+		delete(a.pending, a.next)
+		a.next++
+	}
+	return a.next
+}
@@ -0,0 +1,92 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test backoffWithJitter stays within [0, cap] and respects the cap once
+// base*2^attempt would exceed it
+func TestBackoffWithJitter(t *testing.T) {
+	base := 10 * time.Millisecond
+	max := 50 * time.Millisecond
+
+	for attempt := 0; attempt < 10; attempt++ {
+		d := backoffWithJitter(attempt, base, max)
+		if d < 0 || d > max {
+			t.Errorf("attempt %d: backoff %s out of range [0, %s]", attempt, d, max)
+		}
+	}
+}
+
+// retryableErr lets a test control whether sendWithRetry treats a failure
+// as worth retrying
+type retryableErr struct {
+	retryable bool
+}
+
+func (e *retryableErr) Error() string   { return "send failed" }
+func (e *retryableErr) Retryable() bool { return e.retryable }
+
+// countingTransport fails its first failCount calls, then succeeds
+type countingTransport struct {
+	calls     int
+	failCount int
+	err       error
+}
+
+func (t *countingTransport) Send(ctx context.Context, messages []Message) error {
+	t.calls++
+	if t.calls <= t.failCount {
+		return t.err
+	}
+	return nil
+}
+
+func (t *countingTransport) Close() error { return nil }
+
+// Test sendWithRetry retries a retryable error up to maxRetries and then succeeds
+func TestSendWithRetryRetriesRetryableError(t *testing.T) {
+	transport := &countingTransport{failCount: 2, err: &retryableErr{retryable: true}}
+	b := &messageBatch{ctx: context.Background(), messages: []Message{{Body: "hi"}}}
+
+	err := sendWithRetry(context.Background(), transport, b, 3, time.Millisecond, 10*time.Millisecond)
+	if err != nil {
+		t.Errorf("expected eventual success, got %s", err)
+	}
+	if transport.calls != 3 {
+		t.Errorf("expected 3 calls (2 failures + 1 success), got %d", transport.calls)
+	}
+}
+
+// Test sendWithRetry gives up immediately on a non-retryable error
+func TestSendWithRetryGivesUpOnNonRetryableError(t *testing.T) {
+	wantErr := &retryableErr{retryable: false}
+	transport := &countingTransport{failCount: 100, err: wantErr}
+	b := &messageBatch{ctx: context.Background(), messages: []Message{{Body: "hi"}}}
+
+	err := sendWithRetry(context.Background(), transport, b, 3, time.Millisecond, 10*time.Millisecond)
+	if err != wantErr {
+		t.Errorf("expected immediate non-retryable error, got %s", err)
+	}
+	if transport.calls != 1 {
+		t.Errorf("expected exactly 1 call, got %d", transport.calls)
+	}
+}
+
+// Test sendWithRetry stops after maxRetries and returns the last error
+func TestSendWithRetryStopsAfterMaxRetries(t *testing.T) {
+	wantErr := &retryableErr{retryable: true}
+	transport := &countingTransport{failCount: 100, err: wantErr}
+	b := &messageBatch{ctx: context.Background(), messages: []Message{{Body: "hi"}}}
+
+	err := sendWithRetry(context.Background(), transport, b, 2, time.Millisecond, 10*time.Millisecond)
+	if !errors.Is(err, wantErr) {
+		t.Errorf("expected %s, got %s", wantErr, err)
+	}
+	if transport.calls != 3 { // initial attempt + 2 retries
+		t.Errorf("expected 3 calls (1 initial + 2 retries), got %d", transport.calls)
+	}
+}
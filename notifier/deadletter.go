@@ -0,0 +1,61 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// deadLetterEntry is the JSON shape of a single dead-letter line: enough to
+// let an operator inspect and requeue a message the notifier gave up on
+type deadLetterEntry struct {
+	Body      string    `json:"body"`
+	Topic     string    `json:"topic,omitempty"`
+	ID        string    `json:"id,omitempty"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// deadLetterWriter serializes writes of abandoned messages to w, one JSON
+// object per line, safe for concurrent use by multiple workers. A nil w
+// makes every write a no-op.
+type deadLetterWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// write appends one JSON line describing m, giving up after attempts tries
+// with err
+func (d *deadLetterWriter) write(m Message, topic string, attempts int, err error) {
+	if d == nil || d.w == nil {
+		return
+	}
+
+	entry := deadLetterEntry{
+		Body:      m.Body,
+		Topic:     topic,
+		ID:        m.ID,
+		Attempts:  attempts,
+		Timestamp: time.Now(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	data, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		log.Error("could not encode dead-letter entry: ", marshalErr)
+		return
+	}
+	data = append(data, '\n')
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, writeErr := d.w.Write(data); writeErr != nil {
+		log.Error("could not write dead-letter entry: ", writeErr)
+	}
+}
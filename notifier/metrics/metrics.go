@@ -0,0 +1,51 @@
+// Package metrics exposes Prometheus instrumentation for the notifier package
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// MessagesSent counts messages successfully delivered to a Transport
+	MessagesSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "notifier_messages_sent_total",
+		Help: "Total number of messages successfully delivered",
+	})
+
+	// MessagesFailed counts messages that were ultimately not delivered, by reason
+	MessagesFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notifier_messages_failed_total",
+		Help: "Total number of messages that failed to be delivered, by reason",
+	}, []string{"reason"})
+
+	// SendDuration observes how long a single Transport.Send call took
+	SendDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "notifier_send_duration_seconds",
+		Help: "Duration of a single Transport.Send call",
+	})
+
+	// QueueDepth reports how many batches are currently queued for sending
+	QueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "notifier_queue_depth",
+		Help: "Number of message batches currently queued for sending",
+	})
+
+	// WorkersActive reports how many workers are currently sending a batch
+	WorkersActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "notifier_workers_active",
+		Help: "Number of workers currently sending a batch",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(MessagesSent, MessagesFailed, SendDuration, QueueDepth, WorkersActive)
+}
+
+// Handler returns an http.Handler exposing the registered metrics, suitable
+// for mounting under e.g. /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -1,20 +1,24 @@
-// Sends messages to a server via POST using multiple workers
+// Sends messages to a target via a pluggable Transport using multiple workers
 //
 // Start creates N workers
-// Send receives []Message and each message to a sending channel read by the workers
-// A worker on start creates a custom HTTP client with timeouts, used for sending messages
-// Stop waits for workers to complete the sending
+// Send groups incoming messages by Topic and adds a batch per topic to a channel read by workers
+// A worker reads a batch from the channel and hands it to the configured Transport
+// Shutdown stops new Sends and waits up to its context's deadline for workers to complete
 //
-// Failed messages will be forwarded to an error channel and must be read by the caller before Stop call
+// Failed messages will be forwarded to an error channel and must be read by the caller before Shutdown call
+// Messages the notifier gives up on are also appended to Config.DeadLetter, if configured
 package notifier
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
-	"net"
+	"io"
+	"math/rand"
 	"net/http"
+	"notify/notifier/metrics"
 	"os"
 	"sync"
 	"time"
@@ -23,38 +27,84 @@ import (
 // Default number of workers for sending messages
 const numWorkersDefault = 20
 
-// HTTP Request timeout
-const httpRequestTimeout = 10
+// Default number of retries per message before giving up
+const maxRetriesDefault = 3
 
-// TCP timeout
-const httpTransportTimeout = 5
+// Default base backoff, doubled on every retry
+const baseBackoffDefault = 100 * time.Millisecond
 
-// TSL handshake timeout
-const httpTLSTimeout = 5
+// Default cap for the backoff delay
+const maxBackoffDefault = 5 * time.Second
 
 // Message represent a single message which will be send to a remote server
 type Message struct {
-	Body string // TODO: String method
-	Err  error
+	Body  string // TODO: String method
+	Topic string // Optional topic, used to route and batch the message
+	ID    string // Unique id, auto-generated by Send if empty; used to correlate logs and the X-Request-Id header
+	Err   error
 }
 
-// Notifier manages sending incoming messages to a target url
+// messageBatch groups messages sharing the same topic so they can be
+// routed and delivered together
+type messageBatch struct {
+	topic    string
+	messages []Message
+	ctx      context.Context // caller-supplied context the batch was Send with, used for the Transport call
+	attempt  int             // number of send attempts made so far, used for backoff
+	seq      uint64          // store sequence number, 0 when the batch was never persisted
+}
+
+// requestID returns an id to correlate logs and the X-Request-Id header
+// for the batch, taken from its first message
+func (b *messageBatch) requestID() string {
+	if len(b.messages) == 0 {
+		return ""
+	}
+	return b.messages[0].ID
+}
+
+// Notifier manages sending incoming messages via a Transport
 type Notifier struct {
-	url        string
-	numWorkers int
-	ctx        context.Context
-	stopFn     context.CancelFunc
-	wg         *sync.WaitGroup
-	msgChan    chan Message // buffered channel for sending messages
-	msgErrChan chan Message // buffered channel for failed messages
+	transport   Transport
+	store       Store // durable queue, nil means today's in-memory-only behavior
+	acks        *ackTracker
+	deadLetter  *deadLetterWriter
+	writeMu     sync.Mutex // serializes sequence assignment and store writes
+	seq         uint64     // last sequence number written to the store
+	numWorkers  int
+	maxRetries  int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	ctx         context.Context
+	stopFn      context.CancelFunc
+	wg          *sync.WaitGroup
+	msgChan     chan *messageBatch // buffered channel for sending message batches
+	msgErrChan  chan Message       // buffered channel for failed messages
+
+	shutdownMu   sync.RWMutex // held for read by Send, for write by Shutdown before it closes msgChan
+	closed       bool         // true once Shutdown has started, guarded by shutdownMu
+	shutdownOnce sync.Once    // only the first Shutdown call drains, later calls reuse its result
+	shutdownErr  error
 }
 
 // Config contains all the settings for Notifier
 type Config struct {
-	Url            string // Url of a remote server
-	NumWorkers     int    // Number of workers for sending
-	MsgChanSize    int    // Messages channel size
-	MsgErrChanSize int    // Error channel size
+	Url            string            // Url of a remote server, used to build the default HTTP Transport
+	Routes         map[string]string // Per-topic url overrides, falls back to Url when a topic has no entry
+	BatchEncoding  BatchEncoding     // How a topic's batch is encoded in the request body, defaults to EncodingText
+	Transport      Transport         // Transport used to deliver messages, defaults to HTTP POST to Url/Routes
+	NumWorkers     int               // Number of workers for sending
+	MsgChanSize    int               // Messages channel size
+	MsgErrChanSize int               // Error channel size
+
+	MaxRetries  int           // Max number of retries per message before moving it to the error channel
+	BaseBackoff time.Duration // Base backoff delay, doubled on every retry
+	MaxBackoff  time.Duration // Backoff delay is capped at this value
+
+	StorePath     string // Path to a WAL directory for crash-safe, at-least-once delivery. Empty disables the store
+	StoreMaxBytes int    // Max size of a single WAL segment file, 0 uses the library default
+
+	DeadLetter io.Writer // Receives one JSON line per message the notifier gives up on. Nil disables it
 }
 
 func init() {
@@ -72,8 +122,11 @@ func init() {
 
 // Initialize Notifier with a config
 func NewNotifier(cfg Config) (*Notifier, error) {
-	if cfg.Url == "" {
-		return nil, fmt.Errorf("url is required")
+	if cfg.Transport == nil {
+		if cfg.Url == "" && len(cfg.Routes) == 0 {
+			return nil, fmt.Errorf("url is required")
+		}
+		cfg.Transport = NewHTTPTransport(cfg.Url, cfg.Routes, cfg.BatchEncoding)
 	}
 	// set defaults
 	if cfg.NumWorkers == 0 {
@@ -85,139 +138,389 @@ func NewNotifier(cfg Config) (*Notifier, error) {
 	if cfg.MsgErrChanSize == 0 {
 		cfg.MsgErrChanSize = cfg.NumWorkers * 10
 	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = maxRetriesDefault
+	}
+	if cfg.BaseBackoff == 0 {
+		cfg.BaseBackoff = baseBackoffDefault
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = maxBackoffDefault
+	}
+
+	var store Store
+	var lastSeq uint64
+	var acksFrom uint64 = 1
+	if cfg.StorePath != "" {
+		s, err := NewWALStore(cfg.StorePath, cfg.StoreMaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not open store: %s", err)
+		}
+		store = s
+
+		first, err := store.FirstIndex()
+		if err != nil {
+			return nil, fmt.Errorf("could not read store FirstIndex: %s", err)
+		}
+		last, err := store.LastIndex()
+		if err != nil {
+			return nil, fmt.Errorf("could not read store LastIndex: %s", err)
+		}
+		lastSeq = last
+		acksFrom = first
+		if acksFrom == 0 {
+			acksFrom = last + 1
+		}
+	}
 
 	// Cancellation context to stop workers
 	ctx, stopFn := context.WithCancel(context.Background())
 
 	return &Notifier{
-		url:        cfg.Url,
-		numWorkers: cfg.NumWorkers,
-		ctx:        ctx,
-		stopFn:     stopFn,
-		wg:         &sync.WaitGroup{},
-		msgChan:    make(chan Message, cfg.MsgChanSize),
-		msgErrChan: make(chan Message, cfg.MsgErrChanSize),
+		transport:   cfg.Transport,
+		store:       store,
+		acks:        newAckTracker(acksFrom),
+		deadLetter:  &deadLetterWriter{w: cfg.DeadLetter},
+		seq:         lastSeq,
+		numWorkers:  cfg.NumWorkers,
+		maxRetries:  cfg.MaxRetries,
+		baseBackoff: cfg.BaseBackoff,
+		maxBackoff:  cfg.MaxBackoff,
+		ctx:         ctx,
+		stopFn:      stopFn,
+		wg:          &sync.WaitGroup{},
+		msgChan:     make(chan *messageBatch, cfg.MsgChanSize),
+		msgErrChan:  make(chan Message, cfg.MsgErrChanSize),
 	}, nil
 }
 
-// Start runs workers
+// Start runs workers, and if a Store is configured, replays any batches
+// left unacknowledged by a previous run before new Send calls arrive
 func (n *Notifier) Start() {
 	for i := 0; i < n.numWorkers; i++ {
 		n.wg.Add(1)
-		go worker(n.ctx, i, n.msgChan, n.msgErrChan, n.url, n.wg)
+		go worker(n.ctx, i, n.msgChan, n.msgErrChan, n.transport, n.store, n.acks, n.deadLetter, n.maxRetries, n.baseBackoff, n.maxBackoff, n.wg)
+	}
+
+	if n.store != nil {
+		n.replay()
 	}
 
 	log.Info("started", n.numWorkers, "workers")
 }
 
-// Handle shutdown, wait for all workers to complete
-func (n *Notifier) Stop() {
-	// Drain error channel on cancel
-	defer func() {
-		log.Warning("drop", len(n.msgErrChan), "messages from err channel")
-		for range n.msgErrChan {
+// replay pushes every batch still held by the store onto msgChan, so
+// crashes or restarts do not silently drop unacknowledged messages
+func (n *Notifier) replay() {
+	first, err := n.store.FirstIndex()
+	if err != nil {
+		log.Error("could not read store FirstIndex: ", err)
+		return
+	}
+	if first == 0 {
+		return
+	}
+	last, err := n.store.LastIndex()
+	if err != nil {
+		log.Error("could not read store LastIndex: ", err)
+		return
+	}
+
+	log.Infof("replaying %d unacknowledged batch(es) from store", last-first+1)
+	for seq := first; seq <= last; seq++ {
+		data, err := n.store.Read(seq)
+		if err != nil {
+			log.Error("could not read seq ", seq, " from store: ", err)
+			continue
 		}
-	}()
+		var entry walEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			log.Error("could not decode seq ", seq, " from store: ", err)
+			continue
+		}
+		n.msgChan <- &messageBatch{seq: seq, topic: entry.Topic, messages: entry.Messages, ctx: context.Background()}
+	}
+}
+
+// Shutdown stops Send from accepting new messages and waits up to ctx's
+// deadline for every queued and in-flight batch to be delivered. If the
+// deadline passes first, remaining workers are interrupted and whatever
+// is still queued is dead-lettered. It returns a *ShutdownError wrapping
+// the number of undelivered messages it finds while draining (failed
+// messages still unread in the error channel, plus batches still
+// queued), or nil if it finds none. Failed messages the caller has
+// already read from ErrChan, and messages dropped because the error
+// channel was full, are not counted.
+//
+// Shutdown is safe to call more than once, and concurrently with itself:
+// shutdownMu keeps it from closing msgChan while a Send is in progress,
+// and only the first call actually drains; later calls block until that
+// first call finishes and return its result.
+func (n *Notifier) Shutdown(ctx context.Context) error {
+	n.shutdownMu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.msgChan)
+	}
+	n.shutdownMu.Unlock()
 
-	// no more new messages
-	close(n.msgChan)
+	n.shutdownOnce.Do(func() {
+		n.shutdownErr = n.drain(ctx)
+	})
+	return n.shutdownErr
+}
 
-	// Send stop to workers
-	// n.stopFn() // Disabled: allow to complete all messages
+// drain waits for workers to finish, counts and dead-letters whatever
+// never got delivered, and releases the transport and store. Only ever
+// runs once, via Shutdown's shutdownOnce.
+func (n *Notifier) drain(ctx context.Context) error {
+	workersDone := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(workersDone)
+	}()
 
-	log.Debug("waiting for workers to complete")
-	n.wg.Wait()
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		log.Warn("shutdown deadline reached, interrupting workers")
+		n.stopFn()
+		<-workersDone
+	}
 
-	// no more new errors
 	close(n.msgErrChan)
+	var undelivered int
+	for range n.msgErrChan {
+		undelivered++
+	}
 
-	log.Info("sending is complete")
+	// Batches still sitting in msgChan never reached a worker; this only
+	// has entries when the deadline above cut workers off early
+	for b := range n.msgChan {
+		for _, m := range b.messages {
+			n.deadLetter.write(m, b.topic, b.attempt, ctx.Err())
+			undelivered++
+		}
+	}
+
+	if err := n.transport.Close(); err != nil {
+		log.Error("error closing transport: ", err)
+	}
+
+	if n.store != nil {
+		if err := n.store.Close(); err != nil {
+			log.Error("error closing store: ", err)
+		}
+	}
+
+	log.Info("sending is complete, ", undelivered, " message(s) undelivered")
+	if undelivered > 0 {
+		return &ShutdownError{Undelivered: undelivered}
+	}
+	return nil
 }
 
-// Send adds messages to a channel read by workers
-func (n *Notifier) Send(messages []Message) {
+// ShutdownError is returned by Notifier.Shutdown when it finds undelivered
+// messages while draining
+type ShutdownError struct {
+	Undelivered int
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("shutdown: %d message(s) undelivered", e.Undelivered)
+}
+
+// Send groups messages by topic, persists a batch per topic to the store
+// (when configured), and adds it to a channel read by workers. ctx is
+// attached to each resulting batch and carried through to the Transport
+// call, so callers can bound or cancel delivery.
+//
+// Send holds shutdownMu for read for its whole duration, so a concurrent
+// Shutdown cannot close msgChan out from under it; Shutdown takes the
+// write lock before closing, so it can only proceed once every in-flight
+// Send has returned.
+func (n *Notifier) Send(ctx context.Context, messages []Message) {
+	n.shutdownMu.RLock()
+	defer n.shutdownMu.RUnlock()
+	if n.closed {
+		log.Warn("Send called after Shutdown, dropping ", len(messages), " message(s)")
+		return
+	}
+
 	log.Info("received", len(messages), "messages")
 
-	for _, m := range messages {
-		// Is Blocked when the channel is full
-		n.msgChan <- m
+	byTopic := make(map[string][]Message)
+	var topics []string // preserves first-seen order for deterministic enqueue
+	for i, m := range messages {
+		if m.ID == "" {
+			m.ID = uuid.New().String()
+			messages[i] = m
+		}
+		if _, ok := byTopic[m.Topic]; !ok {
+			topics = append(topics, m.Topic)
+		}
+		byTopic[m.Topic] = append(byTopic[m.Topic], m)
 	}
 
+	for _, topic := range topics {
+		batch := &messageBatch{topic: topic, messages: byTopic[topic], ctx: ctx}
+
+		if n.store != nil {
+			seq, err := n.persist(batch)
+			if err != nil {
+				log.Error("could not persist batch to store: ", err)
+			} else {
+				batch.seq = seq
+			}
+		}
+
+		select {
+		case n.msgChan <- batch:
+		case <-ctx.Done():
+			log.Warn("Send context done, batch for topic ", topic, " was not enqueued")
+		}
+	}
+
+	metrics.QueueDepth.Set(float64(len(n.msgChan)))
 	log.Debug("all messages were added to the sending channel")
 }
 
+// persist appends a batch to the store under the next sequence number
+func (n *Notifier) persist(b *messageBatch) (uint64, error) {
+	data, err := json.Marshal(walEntry{Topic: b.topic, Messages: b.messages})
+	if err != nil {
+		return 0, err
+	}
+
+	n.writeMu.Lock()
+	defer n.writeMu.Unlock()
+
+	seq := n.seq + 1
+	if err := n.store.Write(seq, data); err != nil {
+		return 0, err
+	}
+	n.seq = seq
+
+	return seq, nil
+}
+
 // ErrChan returns a buffered channel to handle failed messages by the caller
 func (n *Notifier) ErrChan() <-chan Message {
 	return n.msgErrChan
 }
 
-// worker: reads messages from msgChan and sends via HTTP POST
-// Failed messages will be added to msgErrChan
-func worker(ctx context.Context, i int, msgChan <-chan Message, msgErrChan chan<- Message, url string, wg *sync.WaitGroup) {
-	defer wg.Done()
+// MetricsHandler returns an http.Handler exposing the package's Prometheus
+// metrics, for callers to mount under e.g. /metrics
+func (n *Notifier) MetricsHandler() http.Handler {
+	return metrics.Handler()
+}
 
-	var err error
-	client := newHTTPClient()
+// worker: reads message batches from msgChan and hands each batch to the
+// Transport, retrying transient failures with exponential backoff before
+// giving up. Failed messages will be added to msgErrChan. When store and
+// acks are non-nil, the store's front is advanced past a batch once it is
+// delivered or permanently given up on.
+func worker(ctx context.Context, i int, msgChan <-chan *messageBatch, msgErrChan chan<- Message, transport Transport, store Store, acks *ackTracker, deadLetter *deadLetterWriter, maxRetries int, baseBackoff, maxBackoff time.Duration, wg *sync.WaitGroup) {
+	defer wg.Done()
 
-	for m := range msgChan {
+	for b := range msgChan {
 		select {
 		case <-ctx.Done():
-			// The worker stops sending new messages, adds current message to err channel and exits
-			log.Debug("worker:", i, "is interrupted")
-
-			select {
-			case msgErrChan <- m:
-				log.Debug("worker:", i, "added current message to err channel before exit")
-			default:
-				log.Error("worker:", i, "err channel is full, could not add current message before exit")
+			// The worker stops sending new batches, adds current batch to err channel and exits
+			// Leave it un-acked so a Store, if configured, replays it on the next start
+			log.Debug("worker:", i, "is interrupted, request-id:", b.requestID())
+			metrics.MessagesFailed.WithLabelValues("interrupted").Add(float64(len(b.messages)))
+
+			for _, m := range b.messages {
+				deadLetter.write(m, b.topic, b.attempt, ctx.Err())
+				select {
+				case msgErrChan <- m:
+				default:
+					log.Error("worker:", i, "err channel is full, could not add current message before exit")
+				}
 			}
 			return
 
 		default:
-			// Sending a message
-			err = sendMessageWithClient(client, url, m.Body)
+			// Sending a batch, retrying transient failures
+			metrics.WorkersActive.Inc()
+			err := sendWithRetry(ctx, transport, b, maxRetries, baseBackoff, maxBackoff)
+			metrics.WorkersActive.Dec()
+
 			if err != nil {
-				// Set the error and move the message into error channel
-				m.Err = err
-				// Is Blocked when the error channel is full
-				// TODO: can be ignored on block
-				msgErrChan <- m
-				continue
+				log.Error("worker:", i, "request-id:", b.requestID(), "batch failed: ", err)
+				metrics.MessagesFailed.WithLabelValues("retries_exhausted").Add(float64(len(b.messages)))
+				// Set the error and move every message in the batch into the error channel
+				for _, m := range b.messages {
+					m.Err = err
+					deadLetter.write(m, b.topic, b.attempt, err)
+					select {
+					case msgErrChan <- m:
+					case <-ctx.Done():
+						log.Error("worker:", i, "err channel is full and shutdown is in progress, dropping message")
+					}
+				}
+			} else {
+				log.Debug("worker:", i, "request-id:", b.requestID(), "batch delivered")
+				metrics.MessagesSent.Add(float64(len(b.messages)))
 			}
+
+			ackBatch(store, acks, b)
 		}
+
+		metrics.QueueDepth.Set(float64(len(msgChan)))
 	}
 	log.Info("worker:", i, "completed")
 }
 
-// Sends a message via POST to url using HTTP client
-func sendMessageWithClient(c *http.Client, url string, body string) error {
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(body)))
-	if err != nil {
-		return err
+// ackBatch advances the store's front past b, once it and every earlier
+// outstanding batch have been handled (delivered or given up on)
+func ackBatch(store Store, acks *ackTracker, b *messageBatch) {
+	if store == nil || b.seq == 0 {
+		return
 	}
-	req.Header.Set("Content-Type", "text/plain")
-	resp, err := c.Do(req)
-	if err != nil {
-		return err
+	boundary := acks.ack(b.seq)
+	if boundary == 0 {
+		return
 	}
-	resp.Body.Close() // we do not need response body
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("request has failed with status code %d", resp.StatusCode)
+	if err := store.TruncateFront(boundary); err != nil {
+		log.Error("could not truncate store front: ", err)
 	}
+}
 
-	return nil
+// sendWithRetry sends a batch via transport, retrying retryable errors
+// with exponential backoff and full jitter. ctx (the worker's shutdown
+// context) governs the sleep between attempts so shutdown stays prompt;
+// b.ctx (the caller's Send context) governs the Transport call itself.
+func sendWithRetry(ctx context.Context, transport Transport, b *messageBatch, maxRetries int, baseBackoff, maxBackoff time.Duration) error {
+	for {
+		start := time.Now()
+		err := transport.Send(b.ctx, b.messages)
+		metrics.SendDuration.Observe(time.Since(start).Seconds())
+		b.attempt++
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) || b.attempt > maxRetries {
+			return err
+		}
+
+		backoff := backoffWithJitter(b.attempt-1, baseBackoff, maxBackoff)
+		log.Debug("retrying batch for topic ", b.topic, " request-id:", b.requestID(), " after ", backoff, ", attempt ", b.attempt)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
 }
 
-// Creates a new custom HTTP client with timeouts: HTTP_TIMEOUT
-func newHTTPClient() *http.Client {
-	return &http.Client{
-		Timeout: time.Second * httpRequestTimeout,
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: httpTransportTimeout * time.Second,
-			}).Dial,
-			TLSHandshakeTimeout: httpTLSTimeout * time.Second,
-		},
+// backoffWithJitter computes base * 2^attempt capped at max, with full jitter
+func backoffWithJitter(attempt int, base, max time.Duration) time.Duration {
+	d := base << uint(attempt)
+	if d <= 0 || d > max {
+		d = max
 	}
+	return time.Duration(rand.Int63n(int64(d)))
 }
@@ -1,6 +1,7 @@
 package notifier
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -8,13 +9,14 @@ import (
 	"time"
 )
 
-// Test basic Send using 2 messages
+// Test basic Send using 2 messages sharing the same (default) topic
 // Test POST method on the target server
-// Test POST body (message content) on the target server
+// Test POST body batches both messages, newline separated
 // Test no failed messages after sending
 func TestSend(t *testing.T) {
 
 	const message = "test message"
+	const batchedBody = message + "\n" + message
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
@@ -27,8 +29,8 @@ func TestSend(t *testing.T) {
 		}
 
 		s := string(body)
-		if s != message {
-			t.Errorf("Expected message %s received %s", message, s)
+		if s != batchedBody {
+			t.Errorf("Expected message %s received %s", batchedBody, s)
 		}
 
 		w.WriteHeader(http.StatusOK)
@@ -48,7 +50,7 @@ func TestSend(t *testing.T) {
 	// Start workers
 	n.Start()
 	// Send message slice
-	n.Send([]Message{
+	n.Send(context.Background(), []Message{
 		Message{
 			Body: message,
 		},
@@ -69,7 +71,7 @@ loop:
 	}
 
 	// Complete Notifier
-	n.Stop()
+	n.Shutdown(context.Background())
 }
 
 // Test receiving failed messages when server is not available
@@ -96,7 +98,7 @@ func TestSendFails(t *testing.T) {
 	// Start workers
 	n.Start()
 	// Send message slice
-	n.Send([]Message{
+	n.Send(context.Background(), []Message{
 		Message{
 			Body: message,
 		},
@@ -127,5 +129,5 @@ loop:
 	}
 
 	// Complete Notifier
-	n.Stop()
+	n.Shutdown(context.Background())
 }
@@ -0,0 +1,60 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestNotifier(t *testing.T) *Notifier {
+	t.Helper()
+	n, err := NewNotifier(Config{
+		Transport:      &fakeTransport{sendFn: func(messages []Message) error { return nil }},
+		NumWorkers:     2,
+		MsgChanSize:    2,
+		MsgErrChanSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewNotifier: %s", err)
+	}
+	return n
+}
+
+// Test Shutdown can be called more than once without panicking, and every
+// call returns the same result
+func TestShutdownIsIdempotent(t *testing.T) {
+	n := newTestNotifier(t)
+	n.Start()
+
+	err1 := n.Shutdown(context.Background())
+	err2 := n.Shutdown(context.Background())
+	if err1 != err2 {
+		t.Errorf("Shutdown results differ between calls: %v, %v", err1, err2)
+	}
+}
+
+// Test a Send racing a concurrent Shutdown never panics with "send on
+// closed channel", and ends up either enqueued or cleanly dropped
+func TestSendDuringShutdownDoesNotPanic(t *testing.T) {
+	n := newTestNotifier(t)
+	n.Start()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			n.Send(context.Background(), []Message{{Body: "hi"}})
+		}
+	}()
+
+	time.Sleep(time.Millisecond)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := n.Shutdown(shutdownCtx); err != nil {
+		t.Logf("Shutdown: %s", err) // undelivered messages are expected here, not a failure
+	}
+
+	wg.Wait()
+}
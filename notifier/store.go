@@ -0,0 +1,25 @@
+package notifier
+
+// Store is a durable, append-only queue for message batches, giving the
+// Notifier at-least-once delivery across restarts. Sequence numbers are
+// monotonically increasing and contiguous: Write expects the next one.
+type Store interface {
+	// Write appends data at seq, the next expected sequence number
+	Write(seq uint64, data []byte) error
+	// Read returns the data previously written at seq
+	Read(seq uint64) ([]byte, error)
+	// FirstIndex returns the oldest retained sequence number, or 0 if the store is empty
+	FirstIndex() (uint64, error)
+	// LastIndex returns the newest sequence number, or 0 if the store is empty
+	LastIndex() (uint64, error)
+	// TruncateFront permanently removes all entries with sequence < seq
+	TruncateFront(seq uint64) error
+	// Close releases resources held by the store
+	Close() error
+}
+
+// walEntry is the JSON-encoded unit stored per sequence number
+type walEntry struct {
+	Topic    string
+	Messages []Message
+}
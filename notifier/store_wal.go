@@ -0,0 +1,57 @@
+package notifier
+
+import (
+	"github.com/tidwall/wal"
+)
+
+// walStore is a Store backed by an on-disk write-ahead log: a
+// monotonically increasing sequence number per entry, held in segmented
+// files so old segments can be dropped once TruncateFront moves past them.
+type walStore struct {
+	log *wal.Log
+}
+
+// NewWALStore opens (creating if necessary) a WAL-backed Store at path.
+// maxBytes caps each segment file; 0 uses the library default.
+func NewWALStore(path string, maxBytes int) (Store, error) {
+	opts := wal.DefaultOptions
+	// ackBatch truncates the front up to the new oldest outstanding seq,
+	// which in steady state (nothing queued behind the acked batch) is
+	// lastIndex+1: TruncateFront rejects that as out of range unless the
+	// log is allowed to become empty
+	opts.AllowEmpty = true
+	if maxBytes > 0 {
+		opts.SegmentSize = maxBytes
+	}
+
+	log, err := wal.Open(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &walStore{log: log}, nil
+}
+
+func (s *walStore) Write(seq uint64, data []byte) error {
+	return s.log.Write(seq, data)
+}
+
+func (s *walStore) Read(seq uint64) ([]byte, error) {
+	return s.log.Read(seq)
+}
+
+func (s *walStore) FirstIndex() (uint64, error) {
+	return s.log.FirstIndex()
+}
+
+func (s *walStore) LastIndex() (uint64, error) {
+	return s.log.LastIndex()
+}
+
+func (s *walStore) TruncateFront(seq uint64) error {
+	return s.log.TruncateFront(seq)
+}
+
+func (s *walStore) Close() error {
+	return s.log.Close()
+}
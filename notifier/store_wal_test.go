@@ -0,0 +1,121 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Test a walStore round-trips writes, tracks First/LastIndex, and
+// TruncateFront permanently drops everything before the given seq
+func TestWALStoreWriteReadTruncate(t *testing.T) {
+	store, err := NewWALStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewWALStore: %s", err)
+	}
+	defer store.Close()
+
+	for seq := uint64(1); seq <= 3; seq++ {
+		if err := store.Write(seq, []byte("entry")); err != nil {
+			t.Fatalf("Write(%d): %s", seq, err)
+		}
+	}
+
+	if first, err := store.FirstIndex(); err != nil || first != 1 {
+		t.Errorf("FirstIndex = %d, %v; want 1, nil", first, err)
+	}
+	if last, err := store.LastIndex(); err != nil || last != 3 {
+		t.Errorf("LastIndex = %d, %v; want 3, nil", last, err)
+	}
+
+	data, err := store.Read(2)
+	if err != nil || string(data) != "entry" {
+		t.Errorf("Read(2) = %q, %v; want \"entry\", nil", data, err)
+	}
+
+	if err := store.TruncateFront(3); err != nil {
+		t.Fatalf("TruncateFront(3): %s", err)
+	}
+	if first, err := store.FirstIndex(); err != nil || first != 3 {
+		t.Errorf("FirstIndex after truncate = %d, %v; want 3, nil", first, err)
+	}
+	if _, err := store.Read(1); err == nil {
+		t.Error("Read(1) after TruncateFront(3): expected an error, got nil")
+	}
+}
+
+// fakeTransport hands every Send call to sendFn, for tests that need to
+// observe what Notifier delivers without a real network hop
+type fakeTransport struct {
+	sendFn func(messages []Message) error
+}
+
+func (t *fakeTransport) Send(ctx context.Context, messages []Message) error {
+	return t.sendFn(messages)
+}
+
+func (t *fakeTransport) Close() error { return nil }
+
+// Test Start replays a batch left in the store by a previous run, and
+// that delivering it truncates the store's front
+func TestNotifierReplaysUnacknowledgedBatches(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewWALStore(dir, 0)
+	if err != nil {
+		t.Fatalf("NewWALStore: %s", err)
+	}
+	data, err := json.Marshal(walEntry{Messages: []Message{{Body: "left over"}}})
+	if err != nil {
+		t.Fatalf("marshal walEntry: %s", err)
+	}
+	if err := store.Write(1, data); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	delivered := make(chan string, 1)
+	transport := &fakeTransport{sendFn: func(messages []Message) error {
+		if len(messages) == 1 {
+			delivered <- messages[0].Body
+		}
+		return nil
+	}}
+
+	n, err := NewNotifier(Config{
+		Transport:      transport,
+		StorePath:      dir,
+		NumWorkers:     1,
+		MsgChanSize:    2,
+		MsgErrChanSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewNotifier: %s", err)
+	}
+	n.Start()
+
+	select {
+	case body := <-delivered:
+		if body != "left over" {
+			t.Errorf("replayed body = %q, want %q", body, "left over")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("replayed batch was never delivered")
+	}
+
+	if err := n.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown: %s", err)
+	}
+
+	reopened, err := NewWALStore(dir, 0)
+	if err != nil {
+		t.Fatalf("reopen store: %s", err)
+	}
+	defer reopened.Close()
+	if first, _ := reopened.FirstIndex(); first != 0 {
+		t.Errorf("expected the replayed batch to be truncated away, FirstIndex = %d", first)
+	}
+}
@@ -0,0 +1,33 @@
+package notifier
+
+import "context"
+
+// Transport delivers a batch of messages to wherever they are headed:
+// a remote HTTP endpoint, a persistent socket, a local file, etc.
+// Implementations must be safe for concurrent use by multiple workers.
+type Transport interface {
+	// Send delivers messages, returning an error if any of them could
+	// not be delivered. Implementations should honor ctx for cancellation.
+	Send(ctx context.Context, messages []Message) error
+	// Close releases any resources held by the transport (connections,
+	// file handles, ...). Called once when the Notifier stops.
+	Close() error
+}
+
+// RetryableError is implemented by transport errors that know whether
+// the failed send is worth retrying. Errors which do not implement it
+// are treated as retryable, since they typically indicate a transient
+// network-level failure.
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+// isRetryable reports whether err is worth retrying
+func isRetryable(err error) bool {
+	retryErr, ok := err.(RetryableError)
+	if !ok {
+		return true
+	}
+	return retryErr.Retryable()
+}
@@ -0,0 +1,44 @@
+package notifier
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+// fileTransport appends each message body to a local file, one per line.
+// Useful as a local buffer when the network is down: an operator can
+// later replay the file through a real transport.
+type fileTransport struct {
+	mu   sync.Mutex // guards writes, *os.File is not safe for concurrent writers
+	file *os.File
+}
+
+// NewFileTransport opens (creating if necessary) an append-only file at path
+func NewFileTransport(path string) (Transport, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileTransport{file: f}, nil
+}
+
+// Send appends each message body to the file followed by a newline
+func (t *fileTransport) Send(ctx context.Context, messages []Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, m := range messages {
+		if _, err := t.file.WriteString(m.Body + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close flushes and closes the underlying file
+func (t *fileTransport) Close() error {
+	return t.file.Close()
+}
@@ -0,0 +1,73 @@
+package notifier
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// Test Send appends each message body followed by a newline, across calls
+func TestFileTransportSendAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+
+	transport, err := NewFileTransport(path)
+	if err != nil {
+		t.Fatalf("NewFileTransport: %s", err)
+	}
+
+	if err := transport.Send(context.Background(), []Message{{Body: "one"}, {Body: "two"}}); err != nil {
+		t.Fatalf("Send: %s", err)
+	}
+	if err := transport.Send(context.Background(), []Message{{Body: "three"}}); err != nil {
+		t.Fatalf("Send: %s", err)
+	}
+	if err := transport.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err)
+	}
+	want := "one\ntwo\nthree\n"
+	if string(data) != want {
+		t.Errorf("file contents = %q, want %q", data, want)
+	}
+}
+
+// Test NewFileTransport appends to, rather than truncates, an existing file
+func TestFileTransportAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+
+	first, err := NewFileTransport(path)
+	if err != nil {
+		t.Fatalf("NewFileTransport: %s", err)
+	}
+	if err := first.Send(context.Background(), []Message{{Body: "one"}}); err != nil {
+		t.Fatalf("Send: %s", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	second, err := NewFileTransport(path)
+	if err != nil {
+		t.Fatalf("NewFileTransport (reopen): %s", err)
+	}
+	if err := second.Send(context.Background(), []Message{{Body: "two"}}); err != nil {
+		t.Fatalf("Send: %s", err)
+	}
+	if err := second.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err)
+	}
+	want := "one\ntwo\n"
+	if string(data) != want {
+		t.Errorf("file contents = %q, want %q", data, want)
+	}
+}
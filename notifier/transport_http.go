@@ -0,0 +1,150 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// HTTP Request timeout
+const httpRequestTimeout = 10
+
+// TCP timeout
+const httpTransportTimeout = 5
+
+// TSL handshake timeout
+const httpTLSTimeout = 5
+
+// BatchEncoding selects how a topic's batch of messages is encoded in the
+// HTTP request body
+type BatchEncoding int
+
+const (
+	// EncodingText joins message bodies with a newline into a single text/plain body
+	EncodingText BatchEncoding = iota
+	// EncodingJSON encodes the batch as a JSON array, sent as application/json
+	EncodingJSON
+	// EncodingMsgpack encodes the batch as msgpack, sent as application/msgpack
+	EncodingMsgpack
+)
+
+// httpTransport delivers a topic's batch of messages as a single HTTP POST,
+// routed per topic via routes (falling back to url)
+type httpTransport struct {
+	url      string
+	routes   map[string]string
+	encoding BatchEncoding
+	client   *http.Client
+}
+
+// NewHTTPTransport creates a Transport which POSTs each batch to the url
+// for its topic (routes[topic], falling back to url) using a custom HTTP
+// client with timeouts
+func NewHTTPTransport(url string, routes map[string]string, encoding BatchEncoding) Transport {
+	return &httpTransport{
+		url:      url,
+		routes:   routes,
+		encoding: encoding,
+		client:   newHTTPClient(),
+	}
+}
+
+// Send POSTs the batch to the url for its topic as a single request
+func (t *httpTransport) Send(ctx context.Context, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	body, contentType, err := t.encodeBatch(messages)
+	if err != nil {
+		return err
+	}
+
+	url := t.url
+	if topic := messages[0].Topic; topic != "" {
+		if routeURL, ok := t.routes[topic]; ok {
+			url = routeURL
+		}
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	if id := messages[0].ID; id != "" {
+		req.Header.Set("X-Request-Id", id)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close() // we do not need response body
+
+	if resp.StatusCode != http.StatusOK {
+		return &httpStatusError{statusCode: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// encodeBatch renders messages according to t.encoding, returning the
+// request body and its content type
+func (t *httpTransport) encodeBatch(messages []Message) ([]byte, string, error) {
+	switch t.encoding {
+	case EncodingJSON:
+		body, err := json.Marshal(messages)
+		return body, "application/json", err
+	case EncodingMsgpack:
+		body, err := msgpack.Marshal(messages)
+		return body, "application/msgpack", err
+	default:
+		bodies := make([]string, len(messages))
+		for i, m := range messages {
+			bodies[i] = m.Body
+		}
+		return []byte(strings.Join(bodies, "\n")), "text/plain", nil
+	}
+}
+
+// Close is a no-op: the underlying http.Client has no persistent resources to release
+func (t *httpTransport) Close() error {
+	return nil
+}
+
+// httpStatusError carries the status code of a failed HTTP response so
+// the worker can decide whether the send is worth retrying
+type httpStatusError struct {
+	statusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("request has failed with status code %d", e.statusCode)
+}
+
+// Retryable reports true for 5xx responses, false for 4xx
+func (e *httpStatusError) Retryable() bool {
+	return e.statusCode >= 500
+}
+
+// Creates a new custom HTTP client with timeouts: HTTP_TIMEOUT
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: httpRequestTimeout * time.Second,
+		Transport: &http.Transport{
+			Dial: (&net.Dialer{
+				Timeout: httpTransportTimeout * time.Second,
+			}).Dial,
+			TLSHandshakeTimeout: httpTLSTimeout * time.Second,
+		},
+	}
+}
@@ -0,0 +1,103 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// Test encodeBatch renders each BatchEncoding as documented
+func TestHTTPTransportEncodeBatch(t *testing.T) {
+	messages := []Message{{Body: "one"}, {Body: "two"}}
+
+	t.Run("text joins bodies with newline", func(t *testing.T) {
+		transport := &httpTransport{encoding: EncodingText}
+		body, contentType, err := transport.encodeBatch(messages)
+		if err != nil {
+			t.Fatalf("encodeBatch: %s", err)
+		}
+		if contentType != "text/plain" {
+			t.Errorf("contentType = %q, want text/plain", contentType)
+		}
+		if string(body) != "one\ntwo" {
+			t.Errorf("body = %q, want %q", body, "one\ntwo")
+		}
+	})
+
+	t.Run("JSON encodes the message slice", func(t *testing.T) {
+		transport := &httpTransport{encoding: EncodingJSON}
+		body, contentType, err := transport.encodeBatch(messages)
+		if err != nil {
+			t.Fatalf("encodeBatch: %s", err)
+		}
+		if contentType != "application/json" {
+			t.Errorf("contentType = %q, want application/json", contentType)
+		}
+		var got []Message
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("json.Unmarshal: %s", err)
+		}
+		if len(got) != 2 || got[0].Body != "one" || got[1].Body != "two" {
+			t.Errorf("decoded %+v, want bodies one, two", got)
+		}
+	})
+
+	t.Run("msgpack encodes the message slice", func(t *testing.T) {
+		transport := &httpTransport{encoding: EncodingMsgpack}
+		body, contentType, err := transport.encodeBatch(messages)
+		if err != nil {
+			t.Fatalf("encodeBatch: %s", err)
+		}
+		if contentType != "application/msgpack" {
+			t.Errorf("contentType = %q, want application/msgpack", contentType)
+		}
+		var got []Message
+		if err := msgpack.Unmarshal(body, &got); err != nil {
+			t.Fatalf("msgpack.Unmarshal: %s", err)
+		}
+		if len(got) != 2 || got[0].Body != "one" || got[1].Body != "two" {
+			t.Errorf("decoded %+v, want bodies one, two", got)
+		}
+	})
+}
+
+// Test Send routes to routes[topic] when present, sets X-Request-Id from
+// the first message, and falls back to url for an unrouted topic
+func TestHTTPTransportSendRoutesAndSetsRequestID(t *testing.T) {
+	var gotPath, gotRequestID string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotRequestID = r.Header.Get("X-Request-Id")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	routeTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer routeTS.Close()
+
+	transport := NewHTTPTransport(ts.URL+"/default", map[string]string{"alerts": routeTS.URL + "/alerts"}, EncodingText)
+
+	if err := transport.Send(context.Background(), []Message{{Body: "hi", ID: "req-1"}}); err != nil {
+		t.Fatalf("Send: %s", err)
+	}
+	if gotPath != "/default" {
+		t.Errorf("unrouted topic path = %q, want /default", gotPath)
+	}
+	if gotRequestID != "req-1" {
+		t.Errorf("X-Request-Id = %q, want req-1", gotRequestID)
+	}
+
+	if err := transport.Send(context.Background(), []Message{{Body: "hi", Topic: "alerts"}}); err != nil {
+		t.Fatalf("Send: %s", err)
+	}
+	if gotPath != "/alerts" {
+		t.Errorf("routed topic path = %q, want /alerts", gotPath)
+	}
+}
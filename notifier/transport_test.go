@@ -0,0 +1,30 @@
+package notifier
+
+import (
+	"errors"
+	"testing"
+)
+
+// Test isRetryable: 5xx httpStatusErrors are retryable, 4xx are not, and
+// errors which don't implement RetryableError default to retryable
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"5xx is retryable", &httpStatusError{statusCode: 503}, true},
+		{"500 is retryable", &httpStatusError{statusCode: 500}, true},
+		{"4xx is not retryable", &httpStatusError{statusCode: 404}, false},
+		{"400 is not retryable", &httpStatusError{statusCode: 400}, false},
+		{"plain error defaults to retryable", errors.New("boom"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
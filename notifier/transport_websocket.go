@@ -0,0 +1,113 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+// Keepalive ping interval for the websocket connection
+const wsPingInterval = 30 * time.Second
+
+// How long to wait for a pong before considering the connection dead
+const wsPongTimeout = 60 * time.Second
+
+// websocketTransport maintains a single persistent connection and writes
+// each message as a text frame. Ping/pong keepalive mirrors the pattern
+// used by the msgbus example: a background goroutine pings on an
+// interval, and a read pump drives gorilla/websocket's pong handler
+// (which only ever fires from inside a read) to reset the read deadline
+// and detect a dead or closed connection.
+type websocketTransport struct {
+	mu   sync.Mutex // guards writes, gorilla connections are not safe for concurrent writers
+	conn *websocket.Conn
+	done chan struct{}
+}
+
+// NewWebSocketTransport dials url (expected to use the ws:// or wss:// scheme)
+// and starts the keepalive goroutine
+func NewWebSocketTransport(url string) (Transport, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &websocketTransport{
+		conn: conn,
+		done: make(chan struct{}),
+	}
+
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+	go t.keepalive()
+	go t.readPump()
+
+	return t, nil
+}
+
+// keepalive pings the connection on an interval until the transport is closed
+func (t *websocketTransport) keepalive() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.mu.Lock()
+			err := t.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsPongTimeout))
+			t.mu.Unlock()
+			if err != nil {
+				log.Error("websocket transport: ping failed: ", err)
+			}
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// readPump discards any application data the server sends and, more
+// importantly, keeps calling ReadMessage so the pong handler set above
+// actually runs: gorilla/websocket only invokes it from inside a read.
+// Returns once the connection is closed or the pong timeout elapses
+// without a read deadline reset; it does not close the connection itself.
+func (t *websocketTransport) readPump() {
+	for {
+		if _, _, err := t.conn.ReadMessage(); err != nil {
+			select {
+			case <-t.done:
+			default:
+				log.Error("websocket transport: read pump stopped: ", err)
+			}
+			return
+		}
+	}
+}
+
+// Send writes each message as a text frame on the shared connection
+func (t *websocketTransport) Send(ctx context.Context, messages []Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, m := range messages {
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = t.conn.SetWriteDeadline(deadline)
+		}
+		if err := t.conn.WriteMessage(websocket.TextMessage, []byte(m.Body)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close stops the keepalive goroutine and closes the underlying connection
+func (t *websocketTransport) Close() error {
+	close(t.done)
+	return t.conn.Close()
+}
@@ -0,0 +1,61 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Test Send writes each message as a text frame the server can read, and
+// Close shuts the connection down without returning an error
+func TestWebSocketTransportSendAndClose(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	received := make(chan string, 2)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("server upgrade: %s", err)
+			return
+		}
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(data)
+		}
+	}))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/"
+	transport, err := NewWebSocketTransport(url)
+	if err != nil {
+		t.Fatalf("NewWebSocketTransport: %s", err)
+	}
+
+	if err := transport.Send(context.Background(), []Message{{Body: "one"}, {Body: "two"}}); err != nil {
+		t.Fatalf("Send: %s", err)
+	}
+
+	for _, want := range []string{"one", "two"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("received %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("server never received %q", want)
+		}
+	}
+
+	if err := transport.Close(); err != nil {
+		t.Errorf("Close: %s", err)
+	}
+}